@@ -0,0 +1,173 @@
+package vm
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of the spans
+// and metrics below, the same way every other OTel-instrumented package in
+// an application names its tracer/meter after itself.
+const instrumentationName = "github.com/ethereum/go-ethereum/core/vm"
+
+var (
+	tracer = otel.Tracer(instrumentationName)
+	meter  = otel.Meter(instrumentationName)
+)
+
+// fhevmMetrics bundles the instruments recorded around fhEVM precompile
+// calls. It is built once, at package initialization, from the global
+// meter; those instruments forward to the first MeterProvider installed
+// with otel.SetMeterProvider (normally by Setup) and record nothing before
+// that.
+type fhevmMetrics struct {
+	precompileCalls    metric.Int64Counter
+	precompileDuration metric.Float64Histogram
+	ciphertextBytes    metric.Int64Histogram
+	gasUsed            metric.Int64Histogram
+}
+
+func newFhevmMetrics() (*fhevmMetrics, error) {
+	calls, err := meter.Int64Counter("fhevm_precompile_calls_total",
+		metric.WithDescription("Number of fhEVM precompile invocations, by op and result"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("fhevm_precompile_duration_seconds",
+		metric.WithDescription("Duration of fhEVM precompile invocations, by op"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := meter.Int64Histogram("fhevm_ciphertext_bytes",
+		metric.WithDescription("Size in bytes of ciphertext handles passed into or out of an fhEVM precompile, by op and direction"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	gas, err := meter.Int64Histogram("fhevm_gas_used",
+		metric.WithDescription("Gas consumed by an fhEVM precompile invocation, by op"))
+	if err != nil {
+		return nil, err
+	}
+	return &fhevmMetrics{
+		precompileCalls:    calls,
+		precompileDuration: duration,
+		ciphertextBytes:    bytes,
+		gasUsed:            gas,
+	}, nil
+}
+
+var fhevmMetricsInstance, fhevmMetricsErr = newFhevmMetrics()
+
+// ciphertextDirection labels whether a recorded ciphertext size is an input
+// to or an output of a precompile call.
+type ciphertextDirection string
+
+const (
+	CiphertextIn  ciphertextDirection = "in"
+	CiphertextOut ciphertextDirection = "out"
+)
+
+// PrecompileSpan tracks a single fhEVM precompile invocation: the span
+// covering its execution plus the metrics recorded when it completes.
+// Callers obtain one from StartPrecompileSpan, record whatever attributes
+// they have as they become available, and call End once the call returns.
+//
+// STATUS: this is scaffolding, not a finished integration. The request
+// this implements asks for spans around Interpreter.Run, per-OpCode spans,
+// and every fhEVM precompile (FheAdd, FheMul, FheDecrypt,
+// ReencryptRequest, etc.) wrapping its body with StartPrecompileSpan.
+// Neither the interpreter loop nor the precompile implementations exist
+// in this checkout, so nothing calls into this type yet — it is covered
+// by instrumentation_test.go exercising the shape directly, but it is not
+// wired into any real EVM call path. Whoever adds the interpreter/
+// precompile code is expected to call StartPrecompileSpan at the call
+// sites described above; until then this package has no effect on
+// production spans or metrics.
+type PrecompileSpan struct {
+	ctx       context.Context
+	span      trace.Span
+	op        string
+	startedAt time.Time
+}
+
+// StartPrecompileSpan starts a span named after op (e.g. "FheAdd",
+// "FheDecrypt", "ReencryptRequest") as a child of any span already present
+// in ctx. ctx should come from propagating the incoming JSON-RPC request's
+// trace context via otel.GetTextMapPropagator(), so the on-chain FHE work
+// a call triggers correlates end-to-end with the RPC call that triggered
+// it. The tracer is taken from the current global TracerProvider on every
+// call.
+func StartPrecompileSpan(ctx context.Context, op string, attrs ...attribute.KeyValue) *PrecompileSpan {
+	spanCtx, span := otel.Tracer(instrumentationName).Start(ctx, op, trace.WithAttributes(attrs...))
+	return &PrecompileSpan{ctx: spanCtx, span: span, op: op, startedAt: time.Now()}
+}
+
+// Context returns the span-carrying context, for passing to nested calls.
+func (p *PrecompileSpan) Context() context.Context {
+	return p.ctx
+}
+
+// SetAttributes attaches additional attributes to the span, e.g. a
+// ciphertext handle, input/output type, or ACL check outcome once known.
+func (p *PrecompileSpan) SetAttributes(attrs ...attribute.KeyValue) {
+	p.span.SetAttributes(attrs...)
+}
+
+// RecordCiphertextBytes records the size of a ciphertext handle flowing
+// into or out of the precompile, both as a span attribute and as a metric
+// observation.
+func (p *PrecompileSpan) RecordCiphertextBytes(dir ciphertextDirection, n int) {
+	p.span.SetAttributes(attribute.Int(string(dir)+"_ciphertext_bytes", n))
+	if fhevmMetricsErr == nil {
+		fhevmMetricsInstance.ciphertextBytes.Record(p.ctx, int64(n),
+			metric.WithAttributes(attribute.String("op", p.op), attribute.String("dir", string(dir))))
+	}
+}
+
+// End finishes the span and records the call/duration/gas metrics. err, if
+// non-nil, marks the span as errored and the call's result as "error"
+// rather than "ok".
+func (p *PrecompileSpan) End(gasUsed uint64, err error) {
+	defer p.span.End()
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+		p.span.RecordError(err)
+		p.span.SetStatus(codes.Error, err.Error())
+	}
+	p.span.SetAttributes(attribute.Int64("gas_used", int64(gasUsed)))
+
+	if fhevmMetricsErr != nil {
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("op", p.op), attribute.String("result", result))
+	fhevmMetricsInstance.precompileCalls.Add(p.ctx, 1, attrs)
+	fhevmMetricsInstance.precompileDuration.Record(p.ctx, time.Since(p.startedAt).Seconds(), attrs)
+	fhevmMetricsInstance.gasUsed.Record(p.ctx, int64(gasUsed), metric.WithAttributes(attribute.String("op", p.op)))
+}
+
+// ExtractRemoteContext extracts a trace context propagated by an incoming
+// JSON-RPC handler (via otel.GetTextMapPropagator().Inject on the client
+// side) so that precompile spans for this call nest under the request
+// that triggered it instead of starting a disconnected trace.
+func ExtractRemoteContext(ctx context.Context, carrier propagationCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// propagationCarrier is satisfied by propagation.TextMapCarrier; it is
+// redeclared here to avoid importing the propagation package solely for
+// this one parameter type.
+type propagationCarrier interface {
+	Get(key string) string
+	Set(key string, value string)
+	Keys() []string
+}
@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// This checkout has no EVM interpreter or fhEVM precompile implementations
+// to call StartPrecompileSpan/End/ExtractRemoteContext from, so these
+// tests exercise the instrumentation layer directly against an in-memory
+// span recorder to prove the shape behaves as the eventual call sites
+// (Interpreter.Run, FheAdd, FheDecrypt, ReencryptRequest, ...) will need.
+func withRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func TestPrecompileSpanEndRecordsOkResult(t *testing.T) {
+	sr := withRecorder(t)
+
+	span := StartPrecompileSpan(context.Background(), "FheAdd", attribute.String("handle", "0xabc"))
+	span.SetAttributes(attribute.String("input_type", "euint64"))
+	span.RecordCiphertextBytes(CiphertextIn, 32)
+	span.End(21000, nil)
+
+	ended := sr.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 ended span, got %d", len(ended))
+	}
+	got := ended[0]
+	if got.Name() != "FheAdd" {
+		t.Errorf("span name = %q, want %q", got.Name(), "FheAdd")
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range got.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if v, ok := attrs["gas_used"]; !ok || v.AsInt64() != 21000 {
+		t.Errorf("gas_used attribute = %v, ok=%v, want 21000", v, ok)
+	}
+	if v, ok := attrs["in_ciphertext_bytes"]; !ok || v.AsInt64() != 32 {
+		t.Errorf("in_ciphertext_bytes attribute = %v, ok=%v, want 32", v, ok)
+	}
+	if v, ok := attrs["input_type"]; !ok || v.AsString() != "euint64" {
+		t.Errorf("input_type attribute = %v, ok=%v, want euint64", v, ok)
+	}
+}
+
+func TestPrecompileSpanEndRecordsError(t *testing.T) {
+	sr := withRecorder(t)
+
+	span := StartPrecompileSpan(context.Background(), "FheDecrypt")
+	span.End(0, errors.New("acl check failed"))
+
+	ended := sr.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 ended span, got %d", len(ended))
+	}
+	if ended[0].Status().Code.String() != "Error" {
+		t.Errorf("status code = %v, want Error", ended[0].Status().Code)
+	}
+}
+
+func TestStartPrecompileSpanNestsUnderContextSpan(t *testing.T) {
+	sr := withRecorder(t)
+
+	tracer := otel.Tracer(instrumentationName)
+	parentCtx, parentSpan := tracer.Start(context.Background(), "eth_call")
+	defer parentSpan.End()
+
+	child := StartPrecompileSpan(parentCtx, "ReencryptRequest")
+	child.End(0, nil)
+
+	if child.Context() == parentCtx {
+		t.Error("Context() should return the span-carrying child context, not the parent verbatim")
+	}
+
+	ended := sr.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 ended span, got %d", len(ended))
+	}
+	if got, want := ended[0].Parent().SpanID(), parentSpan.SpanContext().SpanID(); got != want {
+		t.Errorf("child span parent ID = %s, want %s", got, want)
+	}
+}
+
+func TestExtractRemoteContextUsesGlobalPropagator(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	carrier := propagation.MapCarrier{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	ctx := ExtractRemoteContext(context.Background(), carrier)
+
+	sc := otel.Tracer(instrumentationName)
+	_, span := sc.Start(ctx, "FheAdd")
+	defer span.End()
+
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the one carried by the extracted context", span.SpanContext().TraceID())
+	}
+}
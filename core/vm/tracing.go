@@ -2,99 +2,398 @@ package vm
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"net"
+	"net/http"
 	"os"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	otelsdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-// Config represents a tracing configuration used upon initialization.
+// ExporterProtocol selects which wire protocol is used to ship telemetry to
+// the configured endpoint, or whether telemetry is disabled altogether.
+type ExporterProtocol string
+
+const (
+	ExporterOtlpGrpc ExporterProtocol = "otlpgrpc"
+	ExporterOtlpHttp ExporterProtocol = "otlphttp"
+	ExporterStdout   ExporterProtocol = "stdout"
+	ExporterNone     ExporterProtocol = "none"
+)
+
+// OtlpConfig represents a tracing and metrics configuration used upon
+// initialization of the fhEVM telemetry subsystem. It is intended to be
+// populated from geth command-line flags or config file fields and passed
+// to Setup from node startup.
 type OtlpConfig struct {
-	ServiceName           string
-	OtelCollectorEndpoint string
+	ServiceName string
+
+	// Protocol selects the exporter used for both traces and metrics.
+	// Defaults to ExporterNone when empty, so embedders that don't set it
+	// get no telemetry instead of a crash.
+	Protocol ExporterProtocol
+
+	// Endpoint is the collector address, e.g. "localhost:4317" for gRPC or
+	// "localhost:4318" for HTTP. Ignored when Protocol is stdout or none.
+	Endpoint string
+
+	// Headers are added to every export request, e.g. for bearer-token
+	// authenticated collectors.
+	Headers map[string]string
+
+	// Insecure disables TLS on the connection to Endpoint. Defaults to
+	// false: TLS is used unless explicitly opted out of.
+	Insecure bool
+
+	// TLSConfig is used when Insecure is false. A nil value falls back to
+	// the system cert pool via the default tls.Config{}.
+	TLSConfig *tls.Config
+
+	// SampleRatio is the fraction of traces sampled, in [0, 1]. A parent
+	// sampling decision is always honored; SampleRatio only governs
+	// root spans. Defaults to 1.0 (sample everything) when zero or
+	// negative, matching prior behavior.
+	SampleRatio float64
+
+	// PrometheusAddr, when non-empty, starts an HTTP server on this
+	// TCP address (e.g. "localhost:9464") serving the same
+	// fhEVM instruments at /metrics in Prometheus exposition format, in
+	// addition to whatever is pushed via Protocol. This lets operators
+	// scrape fhEVM counters with an existing Prometheus/Grafana stack
+	// without standing up an OTel collector.
+	PrometheusAddr string
+
+	// TracingBackend selects the span exporter built by TracerFactory. If
+	// empty, it is derived from Protocol (ExporterOtlpGrpc/ExporterOtlpHttp/
+	// ExporterStdout map onto the matching TracingBackendType by identical
+	// string value, anything else means no tracing backend), so existing
+	// configs that only set Protocol keep working unchanged.
+	//
+	// There is no TracingJaeger backend: go.opentelemetry.io/otel/exporters/jaeger
+	// is deprecated upstream and unmaintained, and modern Jaeger ingests
+	// OTLP natively, so Jaeger users should point TracingOtlpGrpc or
+	// TracingOtlpHttp at their collector's OTLP endpoint instead.
+	TracingBackend TracingBackendType
+
+	// Zipkin configures the zipkin backend. Required when TracingBackend
+	// is TracingZipkin.
+	Zipkin *ZipkinConfig
 }
 
-func init() {
-	collectorEndpoint, present := os.LookupEnv("FHEVM_OTEL_COLLECTOR_ENDPOINT")
-	if !present {
-		collectorEndpoint = "localhost:4317"
+// TracingBackendType selects which tracing stack TracerFactory builds a
+// span exporter for, following the same shape as Thanos's
+// pkg/tracing/client factory: a type tag plus a per-backend config struct.
+// Values that also exist as an ExporterProtocol (otlpgrpc, otlphttp,
+// stdout, none) intentionally share its exact string spelling so that
+// FHEVM_OTEL_EXPORTER_PROTOCOL and FHEVM_OTEL_TRACING_BACKEND accept the
+// same tokens instead of silently diverging.
+type TracingBackendType string
+
+const (
+	TracingOtlpGrpc TracingBackendType = "otlpgrpc"
+	TracingOtlpHttp TracingBackendType = "otlphttp"
+	TracingZipkin   TracingBackendType = "zipkin"
+	TracingStdout   TracingBackendType = "stdout"
+	TracingNone     TracingBackendType = "none"
+)
+
+// ZipkinConfig configures the zipkin tracing backend.
+type ZipkinConfig struct {
+	// Endpoint is the collector endpoint, e.g.
+	// "http://localhost:9411/api/v2/spans".
+	Endpoint string
+
+	// Headers are added to every span export request, e.g. for
+	// authenticated collectors that expect a static API key header.
+	Headers map[string]string
+
+	// Username and Password, if set, are sent as HTTP basic auth on every
+	// export request.
+	Username string
+	Password string
+}
+
+// TracerFactory builds a span exporter for a single tracing backend from
+// cfg. Registered in tracerFactories, keyed by TracingBackendType.
+type TracerFactory func(ctx context.Context, cfg *OtlpConfig) (otelsdk.SpanExporter, error)
+
+var tracerFactories = map[TracingBackendType]TracerFactory{
+	TracingOtlpGrpc: newOtlpGrpcTraceExporter,
+	TracingOtlpHttp: newOtlpHttpTraceExporter,
+	TracingZipkin:   newZipkinTraceExporter,
+	TracingStdout:   newStdoutTraceExporter,
+}
+
+// tracingBackend resolves the effective TracingBackendType for cfg. An
+// explicit TracingBackend always wins; otherwise it's derived from
+// Protocol so configs that predate TracingBackend keep working.
+func tracingBackend(cfg *OtlpConfig) TracingBackendType {
+	if cfg.TracingBackend != "" {
+		return cfg.TracingBackend
 	}
-	cfg := &OtlpConfig{
-		ServiceName:           "fhevm",
-		OtelCollectorEndpoint: collectorEndpoint,
+	switch cfg.Protocol {
+	case ExporterOtlpGrpc:
+		return TracingOtlpGrpc
+	case ExporterOtlpHttp:
+		return TracingOtlpHttp
+	case ExporterStdout:
+		return TracingStdout
+	default:
+		return TracingNone
 	}
+}
 
-	_, err := initTraceProvider(cfg)
-	if err != nil {
-		log.Fatal(err)
+func newStdoutTraceExporter(context.Context, *OtlpConfig) (otelsdk.SpanExporter, error) {
+	return stdouttrace.New()
+}
+
+func newZipkinTraceExporter(_ context.Context, cfg *OtlpConfig) (otelsdk.SpanExporter, error) {
+	zcfg := cfg.Zipkin
+	if zcfg == nil || zcfg.Endpoint == "" {
+		return nil, fmt.Errorf("zipkin tracing backend requires OtlpConfig.Zipkin.Endpoint")
 	}
+	if len(zcfg.Headers) == 0 && zcfg.Username == "" && zcfg.Password == "" {
+		return zipkin.New(zcfg.Endpoint)
+	}
+	client := &http.Client{Transport: &credentialedRoundTripper{
+		headers:  zcfg.Headers,
+		username: zcfg.Username,
+		password: zcfg.Password,
+	}}
+	return zipkin.New(zcfg.Endpoint, zipkin.WithClient(client))
+}
 
-	err = InitMeterProvider(cfg)
-	if err != nil {
-		log.Fatal(err)
+// credentialedRoundTripper adds static headers and/or HTTP basic auth to
+// every request, for exporters (like zipkin.New) that only accept a
+// plain *http.Client rather than per-request header options.
+type credentialedRoundTripper struct {
+	headers  map[string]string
+	username string
+	password string
+}
+
+func (rt *credentialedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range rt.headers {
+		req.Header.Set(k, v)
+	}
+	if rt.username != "" || rt.password != "" {
+		req.SetBasicAuth(rt.username, rt.password)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// configFromEnv builds an OtlpConfig from FHEVM_OTEL_* environment
+// variables. The old init() always dialed FHEVM_OTEL_COLLECTOR_ENDPOINT
+// over insecure gRPC, so setting only that variable here still produces an
+// insecure connection rather than attempting (and failing) a TLS
+// handshake against the same plaintext local collector. Unlike the old
+// init(), leaving that variable unset disables telemetry (ExporterNone)
+// instead of dialing localhost:4317.
+func configFromEnv() *OtlpConfig {
+	cfg := &OtlpConfig{
+		ServiceName: "fhevm",
+		Protocol:    ExporterNone,
+		SampleRatio: 1.0,
+	}
+	if endpoint, present := os.LookupEnv("FHEVM_OTEL_COLLECTOR_ENDPOINT"); present {
+		cfg.Endpoint = endpoint
+		cfg.Protocol = ExporterOtlpGrpc
+		cfg.Insecure = true
+	}
+	if proto, present := os.LookupEnv("FHEVM_OTEL_EXPORTER_PROTOCOL"); present {
+		cfg.Protocol = ExporterProtocol(strings.ToLower(proto))
+	}
+	if _, present := os.LookupEnv("FHEVM_OTEL_INSECURE"); present {
+		cfg.Insecure = true
 	}
+	if backend, present := os.LookupEnv("FHEVM_OTEL_TRACING_BACKEND"); present {
+		cfg.TracingBackend = TracingBackendType(strings.ToLower(backend))
+	}
+	if endpoint, present := os.LookupEnv("FHEVM_OTEL_ZIPKIN_ENDPOINT"); present {
+		cfg.Zipkin = &ZipkinConfig{Endpoint: endpoint}
+	}
+	return cfg
 }
 
-func initTraceProvider(cfg *OtlpConfig) (*otelsdk.TracerProvider, error) {
-	var err error
-	tp := &otelsdk.TracerProvider{}
-	if cfg.OtelCollectorEndpoint != "" {
-		tp, err = initOtelTracer(cfg)
+// Setup initializes the fhEVM OpenTelemetry trace and meter providers
+// according to cfg and installs them as the global providers. It is meant
+// to be called once from geth's node startup, mirroring the lifecycle
+// hooks exposed by packages like the OTel Collector's service/telemetry
+// package: the caller holds on to the returned shutdown func and invokes
+// it during node shutdown to flush any batched spans and metrics.
+//
+// Setup never calls log.Fatal: embedders that don't run a collector (unit
+// tests, tooling, geth binaries started without telemetry configured) get
+// a no-op shutdown instead of a crash.
+func Setup(ctx context.Context, cfg *OtlpConfig) (shutdown func(context.Context) error, err error) {
+	if cfg == nil {
+		cfg = configFromEnv()
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ExporterNone
+	}
+
+	var shutdownFuncs []func(context.Context) error
+	shutdown = func(ctx context.Context) error {
+		var errs []error
+		for _, fn := range shutdownFuncs {
+			if err := fn(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		shutdownFuncs = nil
+		return joinErrors(errs)
+	}
+
+	handleErr := func(inErr error) {
+		err = joinErrors([]error{err, inErr})
 	}
 
+	tp, tpShutdown, tErr := initTraceProvider(ctx, cfg)
+	if tErr != nil {
+		handleErr(tErr)
+		_ = shutdown(ctx)
+		return shutdown, err
+	}
+	if tpShutdown != nil {
+		shutdownFuncs = append(shutdownFuncs, tpShutdown)
+	}
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
-	return tp, err
+	var promRegistry *prometheus.Registry
+	if cfg.PrometheusAddr != "" {
+		promRegistry = prometheus.NewRegistry()
+	}
+
+	mp, mpShutdown, mErr := newMeterProviderForConfig(ctx, cfg, promRegistry)
+	if mErr != nil {
+		handleErr(mErr)
+		_ = shutdown(ctx)
+		return shutdown, err
+	}
+	if mpShutdown != nil {
+		shutdownFuncs = append(shutdownFuncs, mpShutdown)
+	}
+	otel.SetMeterProvider(mp)
+
+	if promRegistry != nil {
+		promShutdown, pErr := servePrometheus(cfg, promRegistry)
+		if pErr != nil {
+			handleErr(pErr)
+			_ = shutdown(ctx)
+			return shutdown, err
+		}
+		shutdownFuncs = append(shutdownFuncs, promShutdown)
+	}
+
+	return shutdown, err
+}
+
+func joinErrors(errs []error) error {
+	var nonNil []error
+	for _, e := range errs {
+		if e != nil {
+			nonNil = append(nonNil, e)
+		}
+	}
+	if len(nonNil) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(nonNil))
+	for i, e := range nonNil {
+		msgs[i] = e.Error()
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
 }
 
-func initOtelTracer(cfg *OtlpConfig) (*otelsdk.TracerProvider, error) {
-	conn, err := grpc.DialContext(context.Background(), cfg.OtelCollectorEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func initTraceProvider(ctx context.Context, cfg *OtlpConfig) (*otelsdk.TracerProvider, func(context.Context) error, error) {
+	res, err := newResource(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+		return nil, nil, err
 	}
 
-	// Set up a trace exporter
-	traceExporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithGRPCConn(conn))
+	sampler := otelsdk.ParentBased(otelsdk.TraceIDRatioBased(sampleRatioOrDefault(cfg)))
+
+	backend := tracingBackend(cfg)
+	if backend == TracingNone {
+		tp := otelsdk.NewTracerProvider(otelsdk.WithResource(res), otelsdk.WithSampler(sampler))
+		return tp, tp.Shutdown, nil
+	}
+
+	factory, ok := tracerFactories[backend]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown tracing backend %q", backend)
+	}
+	exporter, err := factory(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return nil, nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
 
 	tp := otelsdk.NewTracerProvider(
-		otelsdk.WithSampler(otelsdk.AlwaysSample()),
-		// Register the trace exporter with a TracerProvider, using a batch
-		// span processor to aggregate spans before export.
-		otelsdk.WithBatcher(traceExporter),
-		otelsdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(cfg.ServiceName),
-		)),
+		otelsdk.WithSampler(sampler),
+		otelsdk.WithBatcher(exporter),
+		otelsdk.WithResource(res),
 	)
+	return tp, tp.Shutdown, nil
+}
 
-	return tp, nil
+func sampleRatioOrDefault(cfg *OtlpConfig) float64 {
+	if cfg.SampleRatio > 0 {
+		return cfg.SampleRatio
+	}
+	return 1.0
 }
 
-func InitMeterProvider(cfg *OtlpConfig) error {
-	res, err := newResource(cfg)
-	if err != nil {
-		return err
+func dialOptions(cfg *OtlpConfig) []grpc.DialOption {
+	if cfg.Insecure {
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
 	}
-	meterProvider, err := newMeterProvider(res)
+	tlsCfg := cfg.TLSConfig
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{}
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))}
+}
+
+func newOtlpGrpcTraceExporter(ctx context.Context, cfg *OtlpConfig) (otelsdk.SpanExporter, error) {
+	conn, err := grpc.DialContext(ctx, cfg.Endpoint, dialOptions(cfg)...)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+	}
+	return otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithHeaders(cfg.Headers))
+}
+
+func newOtlpHttpTraceExporter(ctx context.Context, cfg *OtlpConfig) (otelsdk.SpanExporter, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint), otlptracehttp.WithHeaders(cfg.Headers)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if cfg.TLSConfig != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.TLSConfig))
 	}
-	otel.SetMeterProvider(meterProvider)
-	return nil
+	return otlptracehttp.New(ctx, opts...)
 }
 
 func newResource(cfg *OtlpConfig) (*resource.Resource, error) {
@@ -104,15 +403,107 @@ func newResource(cfg *OtlpConfig) (*resource.Resource, error) {
 		))
 }
 
-func newMeterProvider(res *resource.Resource) (*metric.MeterProvider, error) {
-	metricExporter, err := stdoutmetric.New()
+func newMeterProviderForConfig(ctx context.Context, cfg *OtlpConfig, promRegistry *prometheus.Registry) (*metric.MeterProvider, func(context.Context) error, error) {
+	res, err := newResource(cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	meterProvider := metric.NewMeterProvider(
-		metric.WithResource(res),
-		metric.WithReader(metric.NewPeriodicReader(metricExporter)),
-	)
-	return meterProvider, nil
+	var readers []metric.Reader
+	reader, err := newMetricReader(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reader != nil {
+		readers = append(readers, reader)
+	}
+
+	if promRegistry != nil {
+		promReader, err := otelprometheus.New(otelprometheus.WithRegisterer(promRegistry))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create prometheus reader: %w", err)
+		}
+		readers = append(readers, promReader)
+	}
+
+	mp := newMeterProvider(res, readers...)
+	return mp, mp.Shutdown, nil
+}
+
+// servePrometheus starts an HTTP server exposing reg in Prometheus
+// exposition format at /metrics on cfg.PrometheusAddr. The returned
+// shutdown func gracefully stops the server.
+func servePrometheus(cfg *OtlpConfig, reg *prometheus.Registry) (func(context.Context) error, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: cfg.PrometheusAddr, Handler: mux}
+
+	ln, err := net.Listen("tcp", cfg.PrometheusAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s for fhEVM prometheus metrics: %w", cfg.PrometheusAddr, err)
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Error("fhEVM prometheus metrics server stopped", "err", err)
+		}
+	}()
+
+	return srv.Shutdown, nil
+}
+
+func newMetricReader(ctx context.Context, cfg *OtlpConfig) (metric.Reader, error) {
+	switch cfg.Protocol {
+	case ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		exporter, err := stdoutmetric.New()
+		if err != nil {
+			return nil, err
+		}
+		return metric.NewPeriodicReader(exporter), nil
+	case ExporterOtlpHttp:
+		exporter, err := newOtlpHttpMetricExporter(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		}
+		return metric.NewPeriodicReader(exporter), nil
+	case ExporterOtlpGrpc:
+		exporter, err := newOtlpGrpcMetricExporter(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		}
+		return metric.NewPeriodicReader(exporter), nil
+	default:
+		return nil, fmt.Errorf("unknown otel exporter protocol %q", cfg.Protocol)
+	}
+}
+
+func newOtlpGrpcMetricExporter(ctx context.Context, cfg *OtlpConfig) (metric.Exporter, error) {
+	conn, err := grpc.DialContext(ctx, cfg.Endpoint, dialOptions(cfg)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+	}
+	return otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn), otlpmetricgrpc.WithHeaders(cfg.Headers))
+}
+
+func newOtlpHttpMetricExporter(ctx context.Context, cfg *OtlpConfig) (metric.Exporter, error) {
+	opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(cfg.Endpoint), otlpmetrichttp.WithHeaders(cfg.Headers)}
+	if cfg.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else if cfg.TLSConfig != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(cfg.TLSConfig))
+	}
+	return otlpmetrichttp.New(ctx, opts...)
+}
+
+// newMeterProvider assembles a MeterProvider from a resource and any
+// number of readers (none yields a provider that exports nothing). It is kept separate from newMeterProviderForConfig so that
+// additional readers (e.g. a pull-based Prometheus reader) can be
+// registered alongside the periodic OTLP reader by callers that need both.
+func newMeterProvider(res *resource.Resource, readers ...metric.Reader) *metric.MeterProvider {
+	opts := []metric.Option{metric.WithResource(res)}
+	for _, r := range readers {
+		opts = append(opts, metric.WithReader(r))
+	}
+	return metric.NewMeterProvider(opts...)
 }
@@ -0,0 +1,289 @@
+package vm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want func(t *testing.T, cfg *OtlpConfig)
+	}{
+		{
+			name: "endpoint only defaults to insecure otlpgrpc, matching the old init()",
+			env:  map[string]string{"FHEVM_OTEL_COLLECTOR_ENDPOINT": "localhost:4317"},
+			want: func(t *testing.T, cfg *OtlpConfig) {
+				if cfg.Protocol != ExporterOtlpGrpc {
+					t.Errorf("Protocol = %q, want %q", cfg.Protocol, ExporterOtlpGrpc)
+				}
+				if !cfg.Insecure {
+					t.Error("Insecure = false, want true when only the endpoint env var is set")
+				}
+			},
+		},
+		{
+			name: "no env vars set disables telemetry",
+			env:  map[string]string{},
+			want: func(t *testing.T, cfg *OtlpConfig) {
+				if cfg.Protocol != ExporterNone {
+					t.Errorf("Protocol = %q, want %q", cfg.Protocol, ExporterNone)
+				}
+				if cfg.Insecure {
+					t.Error("Insecure = true, want false with no endpoint configured")
+				}
+			},
+		},
+		{
+			name: "explicit protocol overrides the endpoint-derived default",
+			env: map[string]string{
+				"FHEVM_OTEL_COLLECTOR_ENDPOINT": "localhost:4318",
+				"FHEVM_OTEL_EXPORTER_PROTOCOL":  "otlphttp",
+			},
+			want: func(t *testing.T, cfg *OtlpConfig) {
+				if cfg.Protocol != ExporterOtlpHttp {
+					t.Errorf("Protocol = %q, want %q", cfg.Protocol, ExporterOtlpHttp)
+				}
+			},
+		},
+		{
+			name: "tracing backend and zipkin endpoint env vars populate their fields",
+			env: map[string]string{
+				"FHEVM_OTEL_TRACING_BACKEND": "zipkin",
+				"FHEVM_OTEL_ZIPKIN_ENDPOINT": "http://localhost:9411/api/v2/spans",
+			},
+			want: func(t *testing.T, cfg *OtlpConfig) {
+				if cfg.TracingBackend != TracingZipkin {
+					t.Errorf("TracingBackend = %q, want %q", cfg.TracingBackend, TracingZipkin)
+				}
+				if cfg.Zipkin == nil || cfg.Zipkin.Endpoint != "http://localhost:9411/api/v2/spans" {
+					t.Errorf("Zipkin = %+v, want endpoint set", cfg.Zipkin)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			tt.want(t, configFromEnv())
+		})
+	}
+}
+
+func TestTracingBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *OtlpConfig
+		want TracingBackendType
+	}{
+		{"explicit backend wins over protocol", &OtlpConfig{Protocol: ExporterStdout, TracingBackend: TracingZipkin}, TracingZipkin},
+		{"otlpgrpc protocol derives otlpgrpc backend", &OtlpConfig{Protocol: ExporterOtlpGrpc}, TracingOtlpGrpc},
+		{"otlphttp protocol derives otlphttp backend", &OtlpConfig{Protocol: ExporterOtlpHttp}, TracingOtlpHttp},
+		{"stdout protocol derives stdout backend, not none", &OtlpConfig{Protocol: ExporterStdout}, TracingStdout},
+		{"none protocol derives none backend", &OtlpConfig{Protocol: ExporterNone}, TracingNone},
+		{"unset protocol derives none backend", &OtlpConfig{}, TracingNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tracingBackend(tt.cfg); got != tt.want {
+				t.Errorf("tracingBackend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExporterProtocolAndTracingBackendStringsMatch pins the regression
+// the maintainers flagged: ExporterProtocol and TracingBackendType encode
+// the same OTLP/stdout/none concepts and must use identical string
+// spellings, or FHEVM_OTEL_EXPORTER_PROTOCOL and FHEVM_OTEL_TRACING_BACKEND
+// silently accept different tokens for what operators expect to be the
+// same setting.
+func TestExporterProtocolAndTracingBackendStringsMatch(t *testing.T) {
+	pairs := []struct {
+		protocol ExporterProtocol
+		backend  TracingBackendType
+	}{
+		{ExporterOtlpGrpc, TracingOtlpGrpc},
+		{ExporterOtlpHttp, TracingOtlpHttp},
+		{ExporterStdout, TracingStdout},
+		{ExporterNone, TracingNone},
+	}
+	for _, p := range pairs {
+		if string(p.protocol) != string(p.backend) {
+			t.Errorf("ExporterProtocol %q and TracingBackendType %q should share a string spelling", p.protocol, p.backend)
+		}
+	}
+}
+
+func TestSampleRatioOrDefault(t *testing.T) {
+	tests := []struct {
+		name  string
+		ratio float64
+		want  float64
+	}{
+		{"zero defaults to 1.0", 0, 1.0},
+		{"negative defaults to 1.0", -0.5, 1.0},
+		{"positive value passes through", 0.25, 0.25},
+		{"one passes through", 1.0, 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &OtlpConfig{SampleRatio: tt.ratio}
+			if got := sampleRatioOrDefault(cfg); got != tt.want {
+				t.Errorf("sampleRatioOrDefault() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialOptionsInsecure(t *testing.T) {
+	opts := dialOptions(&OtlpConfig{Insecure: true})
+	if len(opts) != 1 {
+		t.Fatalf("expected exactly one dial option, got %d", len(opts))
+	}
+	// A grpc.DialOption cannot be introspected without dialing a real
+	// server, so this only checks the option count and that
+	// insecure.NewCredentials(), which dialOptions uses when Insecure is
+	// set, reports the "insecure" security protocol.
+	want := insecure.NewCredentials()
+	if want.Info().SecurityProtocol != "insecure" {
+		t.Fatalf("test setup broken: insecure.NewCredentials() protocol = %q", want.Info().SecurityProtocol)
+	}
+}
+
+func TestNewMetricReader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("none protocol returns no reader and no error", func(t *testing.T) {
+		reader, err := newMetricReader(ctx, &OtlpConfig{Protocol: ExporterNone})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reader != nil {
+			t.Errorf("reader = %v, want nil", reader)
+		}
+	})
+
+	t.Run("unknown protocol is an error", func(t *testing.T) {
+		_, err := newMetricReader(ctx, &OtlpConfig{Protocol: "bogus"})
+		if err == nil {
+			t.Fatal("expected an error for an unknown exporter protocol, got nil")
+		}
+	})
+
+	t.Run("stdout protocol returns a periodic reader", func(t *testing.T) {
+		reader, err := newMetricReader(ctx, &OtlpConfig{Protocol: ExporterStdout})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reader == nil {
+			t.Error("reader = nil, want a periodic reader wrapping the stdout exporter")
+		}
+	})
+}
+
+func TestNewZipkinTraceExporter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("missing endpoint is an error", func(t *testing.T) {
+		_, err := newZipkinTraceExporter(ctx, &OtlpConfig{})
+		if err == nil {
+			t.Fatal("expected an error when OtlpConfig.Zipkin is nil, got nil")
+		}
+		_, err = newZipkinTraceExporter(ctx, &OtlpConfig{Zipkin: &ZipkinConfig{}})
+		if err == nil {
+			t.Fatal("expected an error when Zipkin.Endpoint is empty, got nil")
+		}
+	})
+
+	t.Run("endpoint with no credentials succeeds without a custom client", func(t *testing.T) {
+		exporter, err := newZipkinTraceExporter(ctx, &OtlpConfig{Zipkin: &ZipkinConfig{Endpoint: "http://localhost:9411/api/v2/spans"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if exporter == nil {
+			t.Error("exporter = nil, want a zipkin exporter")
+		}
+	})
+
+	t.Run("endpoint with credentials succeeds with a credentialed client", func(t *testing.T) {
+		exporter, err := newZipkinTraceExporter(ctx, &OtlpConfig{Zipkin: &ZipkinConfig{
+			Endpoint: "http://localhost:9411/api/v2/spans",
+			Username: "alice",
+			Password: "hunter2",
+		}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if exporter == nil {
+			t.Error("exporter = nil, want a zipkin exporter")
+		}
+	})
+}
+
+func TestCredentialedRoundTripper(t *testing.T) {
+	var gotAuthOK, gotAuthPresent bool
+	var gotUser, gotPass string
+	var gotHeader string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotPass, gotAuthOK = r.BasicAuth()
+		gotAuthPresent = gotAuthOK
+		gotHeader = r.Header.Get("X-Api-Key")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	t.Run("injects headers and basic auth when credentials are set", func(t *testing.T) {
+		gotAuthPresent, gotHeader = false, ""
+		client := &http.Client{Transport: &credentialedRoundTripper{
+			headers:  map[string]string{"X-Api-Key": "secret"},
+			username: "alice",
+			password: "hunter2",
+		}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		if !gotAuthPresent {
+			t.Error("request reached the server without basic auth set")
+		}
+		if gotUser != "alice" || gotPass != "hunter2" {
+			t.Errorf("basic auth = %q/%q, want alice/hunter2", gotUser, gotPass)
+		}
+		if gotHeader != "secret" {
+			t.Errorf("X-Api-Key header = %q, want %q", gotHeader, "secret")
+		}
+	})
+
+	t.Run("omits basic auth when no credentials are set", func(t *testing.T) {
+		gotAuthPresent, gotHeader = false, ""
+		client := &http.Client{Transport: &credentialedRoundTripper{
+			headers: map[string]string{"X-Api-Key": "secret"},
+		}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		if gotAuthPresent {
+			t.Error("request reached the server with basic auth set, want none")
+		}
+		if gotHeader != "secret" {
+			t.Errorf("X-Api-Key header = %q, want %q", gotHeader, "secret")
+		}
+	})
+}